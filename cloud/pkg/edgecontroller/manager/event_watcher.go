@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// eventChannelBufferSize is the capacity of the channel EventWatcher.Events()
+// returns. It is sized generously because a burst of Warning events
+// (ImagePullBackOff, FailedScheduling, ...) for many pods can arrive at once.
+const eventChannelBufferSize = 1024
+
+// eventRingBufferSize bounds how many recent events EventWatcher remembers
+// per pod UID for deduplication across informer resyncs.
+const eventRingBufferSize = 20
+
+var eventWatcherDroppedEventsTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "edgecontroller_event_watcher_dropped_events_total",
+	Help: "Number of pod Events EventWatcher dropped because Events() was not drained fast enough and the channel buffer filled",
+})
+
+// EventWatcher republishes Kubernetes Events about pods that PodManager is
+// currently shipping to an edge node, so edge nodes can surface the same
+// Warning/Normal events (ImagePullBackOff, FailedScheduling, ...) a kubectl
+// user sees in-cluster. It is driven by a SharedIndexInformer on v1.Event and
+// is kept in sync with PodManager's view of which pods belong to an edge node
+// via trackPod/untrackPod.
+//
+// Events() must be drained by an upstream consumer (e.g. the edge
+// websocket/reconnect handler that also consumes PodManager.Events()) for
+// this to have any effect: nothing in this module reads from it, and once
+// the buffer fills, further events are dropped (see
+// eventWatcherDroppedEventsTotal) rather than delivered. That consumer does
+// not exist anywhere in this tree yet (this package has no callers of its
+// own); wiring it up is a separate, out-of-scope change against whichever
+// component owns the edge connection lifecycle, not something this package
+// can provide on its own.
+type EventWatcher struct {
+	events chan *v1.Event
+
+	mu      sync.Mutex
+	tracked map[types.UID]*eventRingBuffer
+}
+
+// newEventWatcher wires an EventWatcher to si, which must be a
+// SharedIndexInformer for v1.Event. Events for pods that have not been
+// registered with trackPod are dropped, since PodManager only cares about
+// events for pods it is currently shipping to an edge node.
+func newEventWatcher(si cache.SharedIndexInformer) *EventWatcher {
+	ew := &EventWatcher{
+		events:  make(chan *v1.Event, eventChannelBufferSize),
+		tracked: make(map[types.UID]*eventRingBuffer),
+	}
+
+	si.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ew.handle,
+		UpdateFunc: func(_, new interface{}) { ew.handle(new) },
+	})
+
+	return ew
+}
+
+// Events returns the channel of deduplicated Events for pods PodManager is
+// currently tracking for an edge node.
+func (ew *EventWatcher) Events() <-chan *v1.Event {
+	return ew.events
+}
+
+// trackPod starts forwarding events whose involvedObject is uid.
+func (ew *EventWatcher) trackPod(uid types.UID) {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	if _, ok := ew.tracked[uid]; !ok {
+		ew.tracked[uid] = newEventRingBuffer(eventRingBufferSize)
+	}
+}
+
+// untrackPod stops forwarding events for uid. It is called once PodManager
+// has emitted a Deleted event for the pod so the subscription doesn't leak.
+func (ew *EventWatcher) untrackPod(uid types.UID) {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	delete(ew.tracked, uid)
+}
+
+func (ew *EventWatcher) handle(obj interface{}) {
+	event, ok := obj.(*v1.Event)
+	if !ok {
+		klog.Warningf("EventWatcher: unexpected object type %T, dropping event", obj)
+		return
+	}
+	if event.InvolvedObject.Kind != "Pod" {
+		return
+	}
+
+	uid := event.InvolvedObject.UID
+	ew.mu.Lock()
+	buf, tracked := ew.tracked[uid]
+	if tracked && buf.seenOrAdd(event) {
+		ew.mu.Unlock()
+		return
+	}
+	ew.mu.Unlock()
+	if !tracked {
+		return
+	}
+
+	select {
+	case ew.events <- event:
+	default:
+		eventWatcherDroppedEventsTotal.Inc()
+		klog.Warningf("EventWatcher: events channel full, dropping event for pod %s/%s", event.InvolvedObject.Namespace, event.InvolvedObject.Name)
+	}
+}
+
+// eventRingBuffer remembers the last size events observed for a single pod so
+// that resyncing the underlying informer doesn't republish the same event
+// twice.
+type eventRingBuffer struct {
+	keys []string
+	seen map[string]struct{}
+	next int
+}
+
+func newEventRingBuffer(size int) *eventRingBuffer {
+	return &eventRingBuffer{
+		keys: make([]string, size),
+		seen: make(map[string]struct{}, size),
+	}
+}
+
+func eventDedupeKey(e *v1.Event) string {
+	return string(e.UID) + "/" + e.ResourceVersion
+}
+
+// seenOrAdd reports whether e has already been recorded, recording it if not.
+func (b *eventRingBuffer) seenOrAdd(e *v1.Event) bool {
+	key := eventDedupeKey(e)
+	if _, ok := b.seen[key]; ok {
+		return true
+	}
+
+	if evicted := b.keys[b.next]; evicted != "" {
+		delete(b.seen, evicted)
+	}
+	b.keys[b.next] = key
+	b.seen[key] = struct{}{}
+	b.next = (b.next + 1) % len(b.keys)
+
+	return false
+}
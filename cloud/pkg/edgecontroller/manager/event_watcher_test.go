@@ -0,0 +1,96 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestEventWatcher_handle(t *testing.T) {
+	podUID := types.UID("pod-uid-1")
+	event := &v1.Event{
+		ObjectMeta:     metav1.ObjectMeta{UID: "event-1", ResourceVersion: "1"},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", UID: podUID, Namespace: "default", Name: "test-pod"},
+		Reason:         "FailedScheduling",
+		Type:           v1.EventTypeWarning,
+	}
+
+	t.Run("drops events for pods it isn't tracking", func(t *testing.T) {
+		ew := &EventWatcher{events: make(chan *v1.Event, 1), tracked: make(map[types.UID]*eventRingBuffer)}
+		ew.handle(event)
+		select {
+		case <-ew.events:
+			t.Fatal("expected no event to be forwarded for an untracked pod")
+		default:
+		}
+	})
+
+	t.Run("forwards events for a tracked pod", func(t *testing.T) {
+		ew := &EventWatcher{events: make(chan *v1.Event, 1), tracked: make(map[types.UID]*eventRingBuffer)}
+		ew.trackPod(podUID)
+		ew.handle(event)
+		select {
+		case got := <-ew.events:
+			if got.Reason != event.Reason {
+				t.Errorf("got event %v, want %v", got, event)
+			}
+		default:
+			t.Fatal("expected event to be forwarded for a tracked pod")
+		}
+	})
+
+	t.Run("dedupes the same event on resync", func(t *testing.T) {
+		ew := &EventWatcher{events: make(chan *v1.Event, 2), tracked: make(map[types.UID]*eventRingBuffer)}
+		ew.trackPod(podUID)
+		ew.handle(event)
+		ew.handle(event)
+		if len(ew.events) != 1 {
+			t.Errorf("got %d events, want 1 after a resync of the same event", len(ew.events))
+		}
+	})
+
+	t.Run("untrackPod stops forwarding", func(t *testing.T) {
+		ew := &EventWatcher{events: make(chan *v1.Event, 1), tracked: make(map[types.UID]*eventRingBuffer)}
+		ew.trackPod(podUID)
+		ew.untrackPod(podUID)
+		ew.handle(event)
+		select {
+		case <-ew.events:
+			t.Fatal("expected no event to be forwarded after untrackPod")
+		default:
+		}
+	})
+
+	t.Run("counts events dropped when the channel buffer is full", func(t *testing.T) {
+		ew := &EventWatcher{events: make(chan *v1.Event, 1), tracked: make(map[types.UID]*eventRingBuffer)}
+		ew.trackPod(podUID)
+		ew.handle(event) // fills the buffer
+
+		before := testutil.ToFloat64(eventWatcherDroppedEventsTotal)
+		secondEvent := event.DeepCopy()
+		secondEvent.ResourceVersion = "2"
+		ew.handle(secondEvent)
+		if got := testutil.ToFloat64(eventWatcherDroppedEventsTotal); got != before+1 {
+			t.Errorf("eventWatcherDroppedEventsTotal = %v, want %v", got, before+1)
+		}
+	})
+}
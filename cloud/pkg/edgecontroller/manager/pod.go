@@ -0,0 +1,501 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/kubeedge/api/apis/componentconfig/cloudcore/v1alpha1"
+	"github.com/kubeedge/kubeedge/cloud/pkg/common/informers"
+)
+
+// namespaceNameLabel is the well-known label the API server stamps on every
+// Namespace object with the namespace's own name. PodManager only watches
+// Pods, so it matches NamespaceSelector against a synthetic label set built
+// from this key instead of looking the Namespace object up.
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// podEventChannelBufferSize is the capacity of the channel PodManager.Events()
+// returns. Sized the same as eventChannelBufferSize/containerEventChannelBufferSize
+// rather than sourced from config.Buffer, since the EdgeController config type
+// this package vendors from kubeedge/api has no pod-event-specific buffer
+// field.
+const podEventChannelBufferSize = 1024
+
+var podFilteredEventsTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "edgecontroller_pod_filtered_events_total",
+	Help: "Number of pod add/update/delete events PodManager dropped because the pod did not match the configured namespaceSelector/podSelector",
+})
+
+var containerEventsDroppedTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "edgecontroller_pod_container_events_dropped_total",
+	Help: "Number of container lifecycle events PodManager dropped because ContainerEvents() was not drained fast enough and the channel buffer filled",
+})
+
+// PodManager is used to process events of Pod allocated to the edge nodes.
+// It merges add/update/delete events coming from a SharedIndexInformer and,
+// when a namespaceSelector/podSelector has been configured, drops events for
+// pods that don't match so operators can exclude namespaces such as
+// kube-system or only ship a labeled subset of pods down to edge nodes.
+type PodManager struct {
+	realEvents   chan watch.Event
+	mergedEvents chan watch.Event
+
+	namespaceSelector labels.Selector
+	podSelector       labels.Selector
+
+	// eventWatcher forwards Kubernetes Events for the pods merge() is
+	// currently shipping downstream. It is nil if NewPodManager couldn't
+	// obtain an Event informer, or when a PodManager is built directly in tests.
+	eventWatcher *EventWatcher
+
+	// store backs Import, which needs to list the pods currently assigned to
+	// a reconnecting edge node without going through the event stream.
+	store cache.Store
+
+	// containerEvents carries the per-container lifecycle transitions merge()
+	// derives alongside each Modified pod event. Nil if a PodManager was built
+	// directly (e.g. in tests) rather than via NewPodManager.
+	containerEvents chan ContainerLifecycleEvent
+
+	// mu guards pods, inScope and uidKeys, the bookkeeping that tracks what
+	// merge() last emitted downstream for each pod. Both merge() (fed by the
+	// informer via realEvents) and Import (fed by an edge reconnect snapshot)
+	// mutate this state, so both go through applyEvent/setPod/deletePod
+	// rather than keeping their own private view of it.
+	mu      sync.Mutex
+	pods    map[string]*v1.Pod
+	inScope map[string]bool
+	uidKeys map[types.UID]string
+}
+
+// ensureBookkeeping lazily allocates pods/inScope/uidKeys so a PodManager
+// built directly (e.g. in tests), without going through NewPodManager, still
+// works. Callers must hold pm.mu.
+func (pm *PodManager) ensureBookkeeping() {
+	if pm.pods == nil {
+		pm.pods = make(map[string]*v1.Pod)
+	}
+	if pm.inScope == nil {
+		pm.inScope = make(map[string]bool)
+	}
+	if pm.uidKeys == nil {
+		pm.uidKeys = make(map[types.UID]string)
+	}
+}
+
+// setPod records pod as in scope under key. Callers must hold pm.mu.
+func (pm *PodManager) setPod(key string, pod *v1.Pod) {
+	if old, ok := pm.pods[key]; ok && old.UID != pod.UID {
+		delete(pm.uidKeys, old.UID)
+	}
+	pm.pods[key] = pod
+	pm.inScope[key] = true
+	pm.uidKeys[pod.UID] = key
+}
+
+// deletePod forgets key, e.g. once a Deleted event has been emitted for it.
+// Callers must hold pm.mu.
+func (pm *PodManager) deletePod(key string) {
+	if pod, ok := pm.pods[key]; ok {
+		delete(pm.uidKeys, pod.UID)
+	}
+	delete(pm.pods, key)
+	delete(pm.inScope, key)
+}
+
+func selectorOrEverything(ls *metav1.LabelSelector) (labels.Selector, error) {
+	if ls == nil {
+		return labels.Everything(), nil
+	}
+	return metav1.LabelSelectorAsSelector(ls)
+}
+
+// matches reports whether pod is in scope for the configured
+// namespaceSelector/podSelector. A nil selector (including a PodManager built
+// without going through NewPodManager) matches everything.
+func (pm *PodManager) matches(pod *v1.Pod) bool {
+	nsSelector, podSelector := pm.namespaceSelector, pm.podSelector
+	if nsSelector == nil {
+		nsSelector = labels.Everything()
+	}
+	if podSelector == nil {
+		podSelector = labels.Everything()
+	}
+	return nsSelector.Matches(labels.Set{namespaceNameLabel: pod.Namespace}) &&
+		podSelector.Matches(labels.Set(pod.Labels))
+}
+
+// isPodUpdated reports whether new represents a change to old that is worth
+// forwarding downstream. It short-circuits on a matching ResourceVersion to
+// suppress periodic informer resyncs, then uses ClassifyPodChange so that
+// changes merge() doesn't care about (see podChangesToForward) don't trigger
+// a Modified event.
+func isPodUpdated(old, new v1.Pod) bool {
+	if old.ResourceVersion == new.ResourceVersion {
+		return false
+	}
+	return ClassifyPodChange(&old, &new)&podChangesToForward != 0
+}
+
+// merge reads the raw informer events off realEvents, filters out pods that
+// don't match the configured selectors and forwards the rest onto
+// mergedEvents. Bookkeeping (pods/inScope/uidKeys) is shared with Import via
+// setPod/deletePod, so a reconnect backfill and a live informer update can
+// never disagree about what was last emitted for a given pod.
+func (pm *PodManager) merge() {
+	for e := range pm.realEvents {
+		pod, ok := e.Object.(*v1.Pod)
+		if !ok {
+			klog.Warningf("PodManager.merge: unexpected object type %T, dropping event", e.Object)
+			continue
+		}
+
+		podEvent, containerEvents := pm.applyEvent(e.Type, pod)
+		if podEvent != nil {
+			pm.mergedEvents <- *podEvent
+		}
+		for _, ce := range containerEvents {
+			pm.sendContainerEvent(ce)
+		}
+	}
+}
+
+// applyEvent updates pm's shared bookkeeping for pod and returns the
+// Pod-level event merge() should forward (nil if none is warranted) along
+// with any per-container lifecycle transitions observed. It decides what an
+// Added/Modified/Deleted informer event means for a pod; Import does not go
+// through it and applies its own snapshot-diff rules.
+func (pm *PodManager) applyEvent(eventType watch.EventType, pod *v1.Pod) (*watch.Event, []ContainerLifecycleEvent) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.ensureBookkeeping()
+
+	key := pod.Namespace + "/" + pod.Name
+	matched := pm.matches(pod)
+
+	switch eventType {
+	case watch.Added:
+		if !matched {
+			podFilteredEventsTotal.Inc()
+			return nil, nil
+		}
+		evtType := watch.Added
+		if pod.DeletionTimestamp != nil {
+			evtType = watch.Deleted
+			pm.untrackPodEvents(pod.UID)
+		} else {
+			pm.trackPodEvents(pod.UID)
+		}
+		pm.setPod(key, pod)
+		return &watch.Event{Type: evtType, Object: pod}, nil
+
+	case watch.Modified:
+		if !matched {
+			podFilteredEventsTotal.Inc()
+			if pm.inScope[key] {
+				// The pod matched last time we saw it; tell the edge to drop
+				// it so it doesn't go stale there.
+				pm.deletePod(key)
+				pm.untrackPodEvents(pod.UID)
+				return &watch.Event{Type: watch.Deleted, Object: pod}, nil
+			}
+			return nil, nil
+		}
+		old, exists := pm.pods[key]
+		var containerEvents []ContainerLifecycleEvent
+		if exists {
+			containerEvents = containerLifecycleEvents(old, pod, time.Now())
+		}
+		if exists && !isPodUpdated(*old, *pod) {
+			return nil, containerEvents
+		}
+		evtType := watch.Modified
+		if !exists {
+			evtType = watch.Added
+		} else if pod.DeletionTimestamp != nil {
+			evtType = watch.Deleted
+		}
+		if pod.DeletionTimestamp != nil {
+			pm.untrackPodEvents(pod.UID)
+		} else {
+			pm.trackPodEvents(pod.UID)
+		}
+		pm.setPod(key, pod)
+		return &watch.Event{Type: evtType, Object: pod}, containerEvents
+
+	case watch.Deleted:
+		pm.deletePod(key)
+		pm.untrackPodEvents(pod.UID)
+		if matched {
+			return &watch.Event{Type: watch.Deleted, Object: pod}, nil
+		}
+		podFilteredEventsTotal.Inc()
+		return nil, nil
+
+	default:
+		klog.Warningf("PodManager.merge: unsupported event type %s for pod %s, dropping", eventType, key)
+		return nil, nil
+	}
+}
+
+// Events return a channel which is used to send pod events
+func (pm *PodManager) Events() chan watch.Event {
+	return pm.mergedEvents
+}
+
+// EventWatcher returns the subsystem forwarding Kubernetes Events for the
+// pods merge() is currently shipping downstream, or nil if NewPodManager
+// wasn't able to set one up (e.g. the Event informer isn't available). As
+// with EventWatcher.Events() itself, the returned watcher's channel must be
+// drained by an upstream consumer or its buffer will fill and silently drop
+// further events.
+func (pm *PodManager) EventWatcher() *EventWatcher {
+	return pm.eventWatcher
+}
+
+// ContainerEvents returns the channel of per-container lifecycle transitions
+// merge() derives alongside each Modified pod event. Like Events(), it must
+// be drained by an upstream consumer: nothing in this module reads from it,
+// and once the buffer fills, further transitions are dropped (see
+// containerEventsDroppedTotal) rather than delivered. No such consumer exists
+// anywhere in this tree yet; shipping these transitions to an edge component
+// is a separate, out-of-scope change, not something this package can provide
+// on its own.
+func (pm *PodManager) ContainerEvents() <-chan ContainerLifecycleEvent {
+	return pm.containerEvents
+}
+
+func (pm *PodManager) sendContainerEvent(e ContainerLifecycleEvent) {
+	if pm.containerEvents == nil {
+		return
+	}
+	select {
+	case pm.containerEvents <- e:
+	default:
+		containerEventsDroppedTotal.Inc()
+		klog.Warningf("PodManager: container events channel full, dropping lifecycle event for pod %s container %s", e.PodUID, e.ContainerName)
+	}
+}
+
+func (pm *PodManager) trackPodEvents(uid types.UID) {
+	if pm.eventWatcher != nil {
+		pm.eventWatcher.trackPod(uid)
+	}
+}
+
+func (pm *PodManager) untrackPodEvents(uid types.UID) {
+	if pm.eventWatcher != nil {
+		pm.eventWatcher.untrackPod(uid)
+	}
+}
+
+// PodIntegrationOptions configures which pods PodManager ships to edge nodes.
+// It is supplied explicitly by the caller instead of being read off
+// v1alpha1.EdgeController, since the selector-gated pod integration fields it
+// describes haven't landed in the kubeedge/api module this package vendors
+// yet. Pass nil for the previous behavior of shipping every pod.
+type PodIntegrationOptions struct {
+	NamespaceSelector *metav1.LabelSelector
+	PodSelector       *metav1.LabelSelector
+}
+
+// NewPodManager create PodManager by SharedIndexInformer, and gates events on
+// the NamespaceSelector/PodSelector in podIntegration, if any. It also spins
+// up an EventWatcher on a shared v1.Event informer so callers get both the
+// merged pod events and the Kubernetes Event stream for those pods from a
+// single constructor.
+func NewPodManager(config *v1alpha1.EdgeController, si cache.SharedIndexInformer, podIntegration *PodIntegrationOptions) (*PodManager, error) {
+	var nsLabelSelector, podLabelSelector *metav1.LabelSelector
+	if podIntegration != nil {
+		nsLabelSelector = podIntegration.NamespaceSelector
+		podLabelSelector = podIntegration.PodSelector
+	}
+	nsSelector, err := selectorOrEverything(nsLabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse podIntegration.namespaceSelector: %v", err)
+	}
+	podSelector, err := selectorOrEverything(podLabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse podIntegration.podSelector: %v", err)
+	}
+
+	events := make(chan watch.Event, podEventChannelBufferSize)
+	rEvents := make(chan watch.Event, podEventChannelBufferSize)
+
+	pm := &PodManager{
+		realEvents:        rEvents,
+		mergedEvents:      events,
+		namespaceSelector: nsSelector,
+		podSelector:       podSelector,
+		store:             si.GetStore(),
+		containerEvents:   make(chan ContainerLifecycleEvent, containerEventChannelBufferSize),
+	}
+
+	if eventInformer := informers.GetInformersManager().GetKubeInformerFactory().Core().V1().Events().Informer(); eventInformer != nil {
+		pm.eventWatcher = newEventWatcher(eventInformer)
+	}
+
+	si.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			pod, ok := obj.(*v1.Pod)
+			if !ok {
+				klog.Errorf("PodManager: object %+v is not a pod", obj)
+				return
+			}
+			rEvents <- watch.Event{Type: watch.Added, Object: pod}
+		},
+		UpdateFunc: func(_, new interface{}) {
+			pod, ok := new.(*v1.Pod)
+			if !ok {
+				klog.Errorf("PodManager: object %+v is not a pod", new)
+				return
+			}
+			rEvents <- watch.Event{Type: watch.Modified, Object: pod}
+		},
+		DeleteFunc: func(obj interface{}) {
+			pod, ok := obj.(*v1.Pod)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					klog.Errorf("PodManager: couldn't get object from tombstone %+v", obj)
+					return
+				}
+				pod, ok = tombstone.Obj.(*v1.Pod)
+				if !ok {
+					klog.Errorf("PodManager: tombstone contained object that is not a pod %+v", tombstone.Obj)
+					return
+				}
+			}
+			rEvents <- watch.Event{Type: watch.Deleted, Object: pod}
+		},
+	})
+
+	go pm.merge()
+
+	return pm, nil
+}
+
+// PodSnapshotEntry identifies one pod an edge node reports having in its own
+// snapshot, as received over the websocket when it reconnects.
+type PodSnapshotEntry struct {
+	UID             types.UID
+	ResourceVersion string
+}
+
+// Import reconciles a reconnecting edge node's view of its pods, described by
+// snapshot, against the informer cache for nodeName, and synthesizes the
+// minimal set of Added/Modified/Deleted events onto the channel Events()
+// returns so the edge can be driven back to the correct state without a full
+// resync storm. Pods the edge doesn't know about yet are emitted as Added,
+// pods whose ResourceVersion is stale are emitted as Modified with the
+// current object, and pods the edge still has but the cloud no longer does
+// (or that no longer match the configured namespaceSelector/podSelector) are
+// emitted as Deleted. It shares pods/inScope/uidKeys with merge() via
+// setPod/deletePod so a pod imported here and then updated by a later,
+// real informer event is recognized as already known rather than re-added.
+//
+// Import is expected to be called from the edge node reconnect/websocket
+// handler once it has decoded the node's reported pod snapshot; this package
+// does not itself own that handler, so wiring the call in is left to that
+// integration point. No such handler exists anywhere in this tree yet (there
+// is no caller of Import here); adding the real edge-reconnect plumbing is a
+// separate, out-of-scope change against whichever component owns the edge
+// connection lifecycle, not something this package can provide on its own.
+func (pm *PodManager) Import(nodeName string, snapshot []PodSnapshotEntry) {
+	edgeResourceVersions := make(map[types.UID]string, len(snapshot))
+	for _, entry := range snapshot {
+		edgeResourceVersions[entry.UID] = entry.ResourceVersion
+	}
+
+	cloudPods := make(map[types.UID]*v1.Pod)
+	for _, obj := range pm.store.List() {
+		pod, ok := obj.(*v1.Pod)
+		if !ok || pod.Spec.NodeName != nodeName {
+			continue
+		}
+		cloudPods[pod.UID] = pod
+	}
+
+	pm.mu.Lock()
+	pm.ensureBookkeeping()
+
+	var events []watch.Event
+	for uid, pod := range cloudPods {
+		key := pod.Namespace + "/" + pod.Name
+		rv, known := edgeResourceVersions[uid]
+
+		if !pm.matches(pod) {
+			if known {
+				pm.deletePod(key)
+				pm.untrackPodEvents(uid)
+				events = append(events, watch.Event{Type: watch.Deleted, Object: pod})
+			}
+			continue
+		}
+
+		pm.trackPodEvents(uid)
+		switch {
+		case !known:
+			pm.setPod(key, pod)
+			events = append(events, watch.Event{Type: watch.Added, Object: pod})
+		case rv != pod.ResourceVersion:
+			pm.setPod(key, pod)
+			events = append(events, watch.Event{Type: watch.Modified, Object: pod})
+		default:
+			// The edge is already current; still seed the bookkeeping so a
+			// later real update is recognized as a change rather than a
+			// first sighting.
+			pm.setPod(key, pod)
+		}
+	}
+
+	for uid := range edgeResourceVersions {
+		if _, exists := cloudPods[uid]; exists {
+			continue
+		}
+		// Prefer the last-known full object so the edge-side consumer can key
+		// the delete off Namespace/Name as well as UID; fall back to a
+		// UID-only stub only if we never observed this pod ourselves.
+		deletedPod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{UID: uid}}
+		if key, tracked := pm.uidKeys[uid]; tracked {
+			if known, ok := pm.pods[key]; ok {
+				deletedPod = known
+			}
+			pm.deletePod(key)
+		}
+		pm.untrackPodEvents(uid)
+		events = append(events, watch.Event{Type: watch.Deleted, Object: deletedPod})
+	}
+	pm.mu.Unlock()
+
+	for _, e := range events {
+		pm.mergedEvents <- e
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"reflect"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// PodChangeSet is a bitmask describing which aspects of a Pod differ between
+// two observations of it. It lets callers react differently to different
+// kinds of change instead of treating any DeepEqual mismatch the same way.
+type PodChangeSet uint16
+
+const (
+	PodChangeSpec PodChangeSet = 1 << iota
+	PodChangeLabels
+	PodChangeAnnotations
+	PodChangeStatusConditions
+	PodChangeContainerStatuses
+	PodChangeDeletionTimestamp
+	PodChangeOwnerReferences
+)
+
+// Has reports whether every bit set in flag is also set in c.
+func (c PodChangeSet) Has(flag PodChangeSet) bool {
+	return c&flag == flag
+}
+
+// ClassifyPodChange compares old and new and returns the set of aspects that
+// differ between them. It is exported so other managers (configmap, secret)
+// can adopt the same change-classification pattern instead of each rolling
+// their own DeepEqual-based diff.
+func ClassifyPodChange(old, new *v1.Pod) PodChangeSet {
+	var c PodChangeSet
+	if !reflect.DeepEqual(old.Spec, new.Spec) {
+		c |= PodChangeSpec
+	}
+	if !reflect.DeepEqual(old.Labels, new.Labels) {
+		c |= PodChangeLabels
+	}
+	if !reflect.DeepEqual(old.Annotations, new.Annotations) {
+		c |= PodChangeAnnotations
+	}
+	if !reflect.DeepEqual(old.Status.Conditions, new.Status.Conditions) {
+		c |= PodChangeStatusConditions
+	}
+	if !reflect.DeepEqual(old.Status.ContainerStatuses, new.Status.ContainerStatuses) {
+		c |= PodChangeContainerStatuses
+	}
+	if !reflect.DeepEqual(old.DeletionTimestamp, new.DeletionTimestamp) {
+		c |= PodChangeDeletionTimestamp
+	}
+	if !reflect.DeepEqual(old.OwnerReferences, new.OwnerReferences) {
+		c |= PodChangeOwnerReferences
+	}
+	return c
+}
+
+// podChangesToForward is the set of change classes that warrant forwarding a
+// Modified event downstream. Status.Conditions is deliberately excluded: it
+// is usually the edge node itself that produced the change (e.g. kubelet
+// reporting Ready back up), so forwarding it would echo the edge's own
+// update back down to it.
+const podChangesToForward = PodChangeSpec | PodChangeLabels | PodChangeAnnotations |
+	PodChangeContainerStatuses | PodChangeDeletionTimestamp | PodChangeOwnerReferences
@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClassifyPodChange(t *testing.T) {
+	base := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "nginx"},
+		},
+		Spec: v1.PodSpec{Containers: []v1.Container{{Name: "c", Image: "nginx:1.19"}}},
+		Status: v1.PodStatus{
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionFalse}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*v1.Pod)
+		want   PodChangeSet
+	}{
+		{
+			"spec change",
+			func(p *v1.Pod) { p.Spec.Containers[0].Image = "nginx:1.20" },
+			PodChangeSpec,
+		},
+		{
+			"label change",
+			func(p *v1.Pod) { p.Labels = map[string]string{"app": "nginx", "tier": "web"} },
+			PodChangeLabels,
+		},
+		{
+			"status conditions only",
+			func(p *v1.Pod) { p.Status.Conditions[0].Status = v1.ConditionTrue },
+			PodChangeStatusConditions,
+		},
+		{
+			"deletion timestamp set",
+			func(p *v1.Pod) { now := metav1.NewTime(time.Unix(0, 0)); p.DeletionTimestamp = &now },
+			PodChangeDeletionTimestamp,
+		},
+		{
+			"no change",
+			func(p *v1.Pod) {},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newPod := base.DeepCopy()
+			tt.mutate(newPod)
+			got := ClassifyPodChange(base, newPod)
+			if got != tt.want {
+				t.Errorf("ClassifyPodChange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPodUpdated_SuppressesStatusConditionsChurn(t *testing.T) {
+	old := *TestOldPodObject
+	old.ResourceVersion = "1"
+	newPod := old
+	newPod.ResourceVersion = "2"
+	newPod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}
+
+	if isPodUpdated(old, newPod) {
+		t.Error("isPodUpdated() = true, want false for a pure Status.Conditions change")
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// containerEventChannelBufferSize is the capacity of the channel
+// PodManager.ContainerEvents() returns.
+const containerEventChannelBufferSize = 1024
+
+// ContainerLifecycleEvent describes a single container's state transition,
+// derived PLEG-style from two observations of its owning pod. PodManager
+// emits one of these, in addition to the usual Pod-level watch.Event,
+// whenever a container's Waiting/Running/Terminated state changes, it
+// restarts, or its readiness flips, so edge components can react to
+// container-level transitions without re-parsing the whole PodStatus.
+type ContainerLifecycleEvent struct {
+	PodUID        types.UID
+	ContainerName string
+	ContainerID   string
+	Old           v1.ContainerState
+	New           v1.ContainerState
+	Timestamp     time.Time
+}
+
+// containerLifecycleEvents returns the ordered set of ContainerLifecycleEvents
+// between old and new, covering regular, init and ephemeral containers alike.
+// Transitions are emitted in the order each container list appears in new's
+// status (regular, then init, then ephemeral), so transitions of several
+// containers between the two observed versions come out as one ordered batch.
+// Each container yields at most one event, comparing its state in old with its
+// state in new; intermediate states that were never observed are not reported.
+func containerLifecycleEvents(old, new *v1.Pod, now time.Time) []ContainerLifecycleEvent {
+	oldStatuses := indexContainerStatuses(old)
+
+	var events []ContainerLifecycleEvent
+	events = append(events, diffContainerStatuses(new.UID, oldStatuses, new.Status.ContainerStatuses, now)...)
+	events = append(events, diffContainerStatuses(new.UID, oldStatuses, new.Status.InitContainerStatuses, now)...)
+	events = append(events, diffContainerStatuses(new.UID, oldStatuses, new.Status.EphemeralContainerStatuses, now)...)
+	return events
+}
+
+func indexContainerStatuses(pod *v1.Pod) map[string]v1.ContainerStatus {
+	idx := make(map[string]v1.ContainerStatus,
+		len(pod.Status.ContainerStatuses)+len(pod.Status.InitContainerStatuses)+len(pod.Status.EphemeralContainerStatuses))
+	for _, cs := range pod.Status.ContainerStatuses {
+		idx[cs.Name] = cs
+	}
+	for _, cs := range pod.Status.InitContainerStatuses {
+		idx[cs.Name] = cs
+	}
+	for _, cs := range pod.Status.EphemeralContainerStatuses {
+		idx[cs.Name] = cs
+	}
+	return idx
+}
+
+func diffContainerStatuses(podUID types.UID, oldStatuses map[string]v1.ContainerStatus, newStatuses []v1.ContainerStatus, now time.Time) []ContainerLifecycleEvent {
+	var events []ContainerLifecycleEvent
+	for _, newCS := range newStatuses {
+		oldCS, existed := oldStatuses[newCS.Name]
+		if existed && !containerStateChanged(oldCS, newCS) {
+			continue
+		}
+		events = append(events, ContainerLifecycleEvent{
+			PodUID:        podUID,
+			ContainerName: newCS.Name,
+			ContainerID:   newCS.ContainerID,
+			Old:           oldCS.State,
+			New:           newCS.State,
+			Timestamp:     now,
+		})
+	}
+	return events
+}
+
+// containerStateChanged reports whether old and new represent a transition
+// worth surfacing: a Waiting/Running/Terminated change, a restart, or a
+// readiness flip.
+func containerStateChanged(old, new v1.ContainerStatus) bool {
+	return containerStateKind(old.State) != containerStateKind(new.State) ||
+		old.RestartCount != new.RestartCount ||
+		old.Ready != new.Ready
+}
+
+func containerStateKind(s v1.ContainerState) string {
+	switch {
+	case s.Running != nil:
+		return "Running"
+	case s.Terminated != nil:
+		return "Terminated"
+	default:
+		return "Waiting"
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestContainerLifecycleEvents(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		old, new   *v1.Pod
+		wantEvents []ContainerLifecycleEvent
+	}{
+		{
+			"regular container: waiting to running",
+			podWithContainerStatus(v1.ContainerStatus{Name: "app", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{}}}),
+			podWithContainerStatus(v1.ContainerStatus{Name: "app", ContainerID: "docker://abc", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}}),
+			[]ContainerLifecycleEvent{
+				{
+					ContainerName: "app",
+					ContainerID:   "docker://abc",
+					Old:           v1.ContainerState{Waiting: &v1.ContainerStateWaiting{}},
+					New:           v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+				},
+			},
+		},
+		{
+			"regular container: restart count increments with no state change",
+			podWithContainerStatus(v1.ContainerStatus{Name: "app", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}, RestartCount: 1}),
+			podWithContainerStatus(v1.ContainerStatus{Name: "app", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}, RestartCount: 2}),
+			[]ContainerLifecycleEvent{
+				{
+					ContainerName: "app",
+					Old:           v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+					New:           v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+				},
+			},
+		},
+		{
+			"regular container: readiness flips with no state change",
+			podWithContainerStatus(v1.ContainerStatus{Name: "app", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}, Ready: false}),
+			podWithContainerStatus(v1.ContainerStatus{Name: "app", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}, Ready: true}),
+			[]ContainerLifecycleEvent{
+				{
+					ContainerName: "app",
+					Old:           v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+					New:           v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+				},
+			},
+		},
+		{
+			"regular container: no transition is not emitted",
+			podWithContainerStatus(v1.ContainerStatus{Name: "app", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}, Ready: true}),
+			podWithContainerStatus(v1.ContainerStatus{Name: "app", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}, Ready: true}),
+			nil,
+		},
+		{
+			"init container: running to terminated",
+			&v1.Pod{Status: v1.PodStatus{InitContainerStatuses: []v1.ContainerStatus{
+				{Name: "init", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+			}}},
+			&v1.Pod{Status: v1.PodStatus{InitContainerStatuses: []v1.ContainerStatus{
+				{Name: "init", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 0}}},
+			}}},
+			[]ContainerLifecycleEvent{
+				{
+					ContainerName: "init",
+					Old:           v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+					New:           v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 0}},
+				},
+			},
+		},
+		{
+			"ephemeral container: first appearance",
+			&v1.Pod{Status: v1.PodStatus{}},
+			&v1.Pod{Status: v1.PodStatus{EphemeralContainerStatuses: []v1.ContainerStatus{
+				{Name: "debugger", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+			}}},
+			[]ContainerLifecycleEvent{
+				{
+					ContainerName: "debugger",
+					Old:           v1.ContainerState{},
+					New:           v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := containerLifecycleEvents(tt.old, tt.new, now)
+			if len(got) != len(tt.wantEvents) {
+				t.Fatalf("containerLifecycleEvents() returned %d events, want %d: %+v", len(got), len(tt.wantEvents), got)
+			}
+			for i, want := range tt.wantEvents {
+				if got[i].ContainerName != want.ContainerName ||
+					got[i].ContainerID != want.ContainerID ||
+					containerStateKind(got[i].Old) != containerStateKind(want.Old) ||
+					containerStateKind(got[i].New) != containerStateKind(want.New) {
+					t.Errorf("event %d = %+v, want %+v", i, got[i], want)
+				}
+				if got[i].Timestamp != now {
+					t.Errorf("event %d Timestamp = %v, want %v", i, got[i].Timestamp, now)
+				}
+			}
+		})
+	}
+}
+
+func podWithContainerStatus(cs v1.ContainerStatus) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Status:     v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{cs}},
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2021 The KubeEdge Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPodManager_Import(t *testing.T) {
+	const nodeName = "edge-node1"
+
+	stalePod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "stale-pod", Namespace: "default", UID: "uid-stale", ResourceVersion: "2"},
+		Spec:       v1.PodSpec{NodeName: nodeName},
+	}
+	upToDatePod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "ok-pod", Namespace: "default", UID: "uid-ok", ResourceVersion: "1"},
+		Spec:       v1.PodSpec{NodeName: nodeName},
+	}
+
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	if err := store.Add(stalePod); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(upToDatePod); err != nil {
+		t.Fatal(err)
+	}
+
+	goneButTrackedPod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "gone-pod", Namespace: "default", UID: "uid-gone-tracked", ResourceVersion: "1"},
+		Spec:       v1.PodSpec{NodeName: nodeName},
+	}
+
+	pm := &PodManager{
+		mergedEvents: make(chan watch.Event, 10),
+		store:        store,
+	}
+	pm.mu.Lock()
+	pm.ensureBookkeeping()
+	pm.setPod(goneButTrackedPod.Namespace+"/"+goneButTrackedPod.Name, goneButTrackedPod)
+	pm.mu.Unlock()
+
+	pm.Import(nodeName, []PodSnapshotEntry{
+		// stalePod: edge has resourceVersion 1, cloud has 2 -> Modified.
+		{UID: "uid-stale", ResourceVersion: "1"},
+		// upToDatePod: edge is already current -> no event.
+		{UID: "uid-ok", ResourceVersion: "1"},
+		// gonePod: edge still has it, cloud no longer does, and we never saw
+		// it ourselves -> Deleted with a UID-only stub.
+		{UID: "uid-gone", ResourceVersion: "1"},
+		// goneButTrackedPod: edge still has it, cloud no longer does, but we
+		// previously tracked it -> Deleted with the last-known full object.
+		{UID: goneButTrackedPod.UID, ResourceVersion: "1"},
+	})
+	close(pm.mergedEvents)
+
+	var got []watch.Event
+	for e := range pm.mergedEvents {
+		got = append(got, e)
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("Import() emitted %d events, want 3: %+v", len(got), got)
+	}
+
+	var sawModifiedStale, sawDeletedGone, sawDeletedGoneTracked bool
+	for _, e := range got {
+		pod, ok := e.Object.(*v1.Pod)
+		if !ok {
+			t.Fatalf("event object is not a pod: %+v", e.Object)
+		}
+		switch {
+		case e.Type == watch.Modified && pod.UID == "uid-stale":
+			if pod.ResourceVersion != "2" {
+				t.Errorf("Modified event for uid-stale has ResourceVersion %q, want %q", pod.ResourceVersion, "2")
+			}
+			sawModifiedStale = true
+		case e.Type == watch.Deleted && pod.UID == "uid-gone":
+			if pod.Namespace != "" || pod.Name != "" {
+				t.Errorf("Deleted event for a never-tracked pod should be UID-only, got %+v", pod)
+			}
+			sawDeletedGone = true
+		case e.Type == watch.Deleted && pod.UID == goneButTrackedPod.UID:
+			if pod.Namespace != goneButTrackedPod.Namespace || pod.Name != goneButTrackedPod.Name {
+				t.Errorf("Deleted event for a previously tracked pod should carry its Namespace/Name, got %+v", pod)
+			}
+			sawDeletedGoneTracked = true
+		default:
+			t.Errorf("unexpected event: type=%s uid=%s", e.Type, pod.UID)
+		}
+	}
+	if !sawModifiedStale {
+		t.Error("Import() did not emit a Modified event for the pod with a stale ResourceVersion")
+	}
+	if !sawDeletedGone {
+		t.Error("Import() did not emit a Deleted event for the pod the edge reports that no longer exists in the cloud")
+	}
+	if !sawDeletedGoneTracked {
+		t.Error("Import() did not emit a Deleted event carrying the last-known object for a previously tracked pod")
+	}
+}
@@ -20,10 +20,13 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
 
@@ -32,6 +35,62 @@ import (
 	"github.com/kubeedge/kubeedge/cloud/pkg/common/informers"
 )
 
+var (
+	// TestOldPodObject and TestNewPodObject represent the same pod before and
+	// after an update that bumps the image and the resource version.
+	TestOldPodObject = &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-pod",
+			Namespace:       "default",
+			ResourceVersion: "100",
+		},
+		Spec: v1.PodSpec{
+			NodeName:   "edge-node1",
+			Containers: []v1.Container{{Name: "test-container", Image: "nginx:1.19"}},
+		},
+	}
+	TestNewPodObject = &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-pod",
+			Namespace:       "default",
+			ResourceVersion: "101",
+		},
+		Spec: v1.PodSpec{
+			NodeName:   "edge-node1",
+			Containers: []v1.Container{{Name: "test-container", Image: "nginx:1.20"}},
+		},
+	}
+	// TestDeletingPodObject is TestOldPodObject with a DeletionTimestamp set.
+	TestDeletingPodObject = &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "test-pod",
+			Namespace:         "default",
+			ResourceVersion:   "100",
+			DeletionTimestamp: &metav1.Time{Time: time.Now()},
+		},
+		Spec: TestOldPodObject.Spec,
+	}
+)
+
+const mockKubeConfigContent = `
+apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://127.0.0.1:6443
+  name: test-cluster
+contexts:
+- context:
+    cluster: test-cluster
+    user: test-user
+  name: test-context
+current-context: test-context
+users:
+- name: test-user
+  user:
+    token: test-token
+`
+
 func TestIsPodUpdated(t *testing.T) {
 	type args struct {
 		old *v1.Pod
@@ -68,6 +127,102 @@ func TestIsPodUpdated(t *testing.T) {
 	}
 }
 
+func TestPodManager_matches(t *testing.T) {
+	systemPod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"}}
+	labeledPod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Labels: map[string]string{"edge": "true"}}}
+	plainPod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+
+	nsSelector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: namespaceNameLabel, Operator: metav1.LabelSelectorOpNotIn, Values: []string{"kube-system"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	podSelector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{MatchLabels: map[string]string{"edge": "true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		pm   *PodManager
+		pod  *v1.Pod
+		want bool
+	}{
+		{"no selectors configured matches everything", &PodManager{}, systemPod, true},
+		{"namespaceSelector excludes kube-system", &PodManager{namespaceSelector: nsSelector}, systemPod, false},
+		{"podSelector requires the edge label", &PodManager{podSelector: podSelector}, plainPod, false},
+		{"podSelector matches a labeled pod", &PodManager{podSelector: podSelector}, labeledPod, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pm.matches(tt.pod); got != tt.want {
+				t.Errorf("PodManager.matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPodManager_merge_SynthesizesDeleteWhenPodStopsMatching(t *testing.T) {
+	podSelector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{MatchLabels: map[string]string{"edge": "true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm := &PodManager{
+		realEvents:   make(chan watch.Event, 1),
+		mergedEvents: make(chan watch.Event, 1),
+		podSelector:  podSelector,
+	}
+	go pm.merge()
+
+	matchingPod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "app",
+			Namespace:       "default",
+			ResourceVersion: "1",
+			Labels:          map[string]string{"edge": "true"},
+		},
+	}
+	pm.realEvents <- watch.Event{Type: watch.Added, Object: matchingPod}
+
+	select {
+	case e := <-pm.mergedEvents:
+		if e.Type != watch.Added {
+			t.Fatalf("got event type %s, want Added", e.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the initial Added event")
+	}
+
+	noLongerMatchingPod := matchingPod.DeepCopy()
+	noLongerMatchingPod.ResourceVersion = "2"
+	noLongerMatchingPod.Labels = nil
+	pm.realEvents <- watch.Event{Type: watch.Modified, Object: noLongerMatchingPod}
+
+	select {
+	case e := <-pm.mergedEvents:
+		if e.Type != watch.Deleted {
+			t.Errorf("got event type %s, want Deleted once the pod stopped matching podSelector", e.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the synthetic Deleted event")
+	}
+}
+
+func TestPodManager_sendContainerEvent_CountsDropsWhenBufferFull(t *testing.T) {
+	pm := &PodManager{containerEvents: make(chan ContainerLifecycleEvent, 1)}
+	pm.sendContainerEvent(ContainerLifecycleEvent{ContainerName: "app"}) // fills the buffer
+
+	before := testutil.ToFloat64(containerEventsDroppedTotal)
+	pm.sendContainerEvent(ContainerLifecycleEvent{ContainerName: "app"})
+	if got := testutil.ToFloat64(containerEventsDroppedTotal); got != before+1 {
+		t.Errorf("containerEventsDroppedTotal = %v, want %v", got, before+1)
+	}
+}
+
 func TestPodManager_merge(t *testing.T) {
 	type fields struct {
 		realEvents   chan watch.Event
@@ -200,7 +355,8 @@ func TestPodManager_Events(t *testing.T) {
 
 func TestNewPodManager(t *testing.T) {
 	type args struct {
-		informer cache.SharedIndexInformer
+		informer       cache.SharedIndexInformer
+		podIntegration *PodIntegrationOptions
 	}
 
 	config := &v1alpha1.EdgeController{
@@ -233,14 +389,28 @@ func TestNewPodManager(t *testing.T) {
 		{
 			"TestNewPodManager(): Case 1: with nodename",
 			args{
-				informers.GetInformersManager().GetKubeInformerFactory().Core().V1().Pods().Informer(),
+				informer: informers.GetInformersManager().GetKubeInformerFactory().Core().V1().Pods().Informer(),
+			},
+		},
+		{
+			"TestNewPodManager(): Case 2: with a podIntegration selector",
+			args{
+				informer: informers.GetInformersManager().GetKubeInformerFactory().Core().V1().Pods().Informer(),
+				podIntegration: &PodIntegrationOptions{
+					PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"edge": "true"}},
+				},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err = NewPodManager(config, tt.args.informer)
+			pm, err := NewPodManager(config, tt.args.informer, tt.args.podIntegration)
 			assert.NoError(t, err)
+			if tt.args.podIntegration != nil && tt.args.podIntegration.PodSelector != nil {
+				wantSelector, selErr := metav1.LabelSelectorAsSelector(tt.args.podIntegration.PodSelector)
+				assert.NoError(t, selErr)
+				assert.Equal(t, wantSelector.String(), pm.podSelector.String())
+			}
 		})
 	}
 }